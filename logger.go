@@ -0,0 +1,137 @@
+package loggly
+
+import (
+	"runtime"
+	"strconv"
+)
+
+// errKey is substituted for the value of an odd, unpaired key in a
+// keyvals list so a mistake in call-site arity still produces a valid
+// event instead of panicking.
+const errKey = "MISSING_VALUE"
+
+// Logger is a structured logger that carries a set of key/value context
+// fields, inherited from its parent via With. Fields are copy-on-write, so
+// creating a child Logger is cheap and never mutates the parent.
+type Logger struct {
+	client *Client
+	fields []interface{}
+}
+
+// With returns a child Logger carrying the given key/value context
+// fields in addition to anything already set on c.
+func (c *Client) With(keyvals ...interface{}) *Logger {
+	return (&Logger{client: c}).With(keyvals...)
+}
+
+// With returns a child Logger carrying keyvals in addition to any fields
+// already set on l.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	keyvals = normalizeKeyvals(keyvals)
+
+	fields := make([]interface{}, len(l.fields), len(l.fields)+len(keyvals))
+	copy(fields, l.fields)
+	fields = append(fields, keyvals...)
+
+	return &Logger{client: l.client, fields: fields}
+}
+
+// WithError returns a child Logger carrying `err` as the "err" field along
+// with a "stack" field naming the caller's file and line.
+func (l *Logger) WithError(err error) *Logger {
+	child := l.With("err", err.Error())
+
+	if _, file, line, ok := runtime.Caller(1); ok {
+		child = child.With("stack", file+":"+strconv.Itoa(line))
+	}
+
+	return child
+}
+
+// log builds the event for msg and keyvals, merges in the Logger's
+// context fields, and sends it through the Client's buffered pipeline.
+// Like Client.Debug/Info/..., this gates on Client.Level before Send is
+// ever called, so a Sink wanting a lower level than Client.Level (see
+// Client.Sinks) still won't receive anything below Client.Level.
+func (l *Logger) log(level Level, msg string, keyvals ...interface{}) error {
+	if l.client.Level > level {
+		return nil
+	}
+
+	event := map[string]interface{}{
+		"level": levelNames[level],
+		"msg":   msg,
+	}
+	l.applyFields(event)
+	applyKeyvals(event, keyvals)
+
+	return l.client.Send(event)
+}
+
+func (l *Logger) applyFields(event map[string]interface{}) {
+	for i := 0; i < len(l.fields); i += 2 {
+		if key, ok := l.fields[i].(string); ok {
+			event[key] = l.fields[i+1]
+		}
+	}
+}
+
+// normalizeKeyvals pads an odd-length keyvals list with errKey so it can
+// always be walked in pairs.
+func normalizeKeyvals(keyvals []interface{}) []interface{} {
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, errKey)
+	}
+	return keyvals
+}
+
+func applyKeyvals(event map[string]interface{}, keyvals []interface{}) {
+	keyvals = normalizeKeyvals(keyvals)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = errKey
+		}
+		event[key] = keyvals[i+1]
+	}
+}
+
+// Debug log.
+func (l *Logger) Debug(msg string, keyvals ...interface{}) error {
+	return l.log(DEBUG, msg, keyvals...)
+}
+
+// Info log.
+func (l *Logger) Info(msg string, keyvals ...interface{}) error {
+	return l.log(INFO, msg, keyvals...)
+}
+
+// Notice log.
+func (l *Logger) Notice(msg string, keyvals ...interface{}) error {
+	return l.log(NOTICE, msg, keyvals...)
+}
+
+// Warn log.
+func (l *Logger) Warn(msg string, keyvals ...interface{}) error {
+	return l.log(WARNING, msg, keyvals...)
+}
+
+// Error log.
+func (l *Logger) Error(msg string, keyvals ...interface{}) error {
+	return l.log(ERROR, msg, keyvals...)
+}
+
+// Critical log.
+func (l *Logger) Critical(msg string, keyvals ...interface{}) error {
+	return l.log(CRITICAL, msg, keyvals...)
+}
+
+// Alert log.
+func (l *Logger) Alert(msg string, keyvals ...interface{}) error {
+	return l.log(ALERT, msg, keyvals...)
+}
+
+// Emergency log.
+func (l *Logger) Emergency(msg string, keyvals ...interface{}) error {
+	return l.log(EMERGENCY, msg, keyvals...)
+}
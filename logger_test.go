@@ -0,0 +1,135 @@
+package loggly
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// captureSink records every batch it's asked to write, decoded as JSON.
+type captureSink struct {
+	mu   sync.Mutex
+	msgs []map[string]interface{}
+}
+
+func (s *captureSink) Write(batch [][]byte, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range batch {
+		var msg map[string]interface{}
+		if err := json.Unmarshal(b, &msg); err != nil {
+			return err
+		}
+		s.msgs = append(s.msgs, msg)
+	}
+	return nil
+}
+
+func (s *captureSink) Close() error { return nil }
+
+func (s *captureSink) last() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.msgs[len(s.msgs)-1]
+}
+
+func newCaptureClient(t *testing.T) (*Client, *captureSink) {
+	t.Helper()
+	capture := &captureSink{}
+	c := New("token", 1, false, SinkConfig{Sink: capture, Level: DEBUG})
+	c.Level = DEBUG
+	t.Cleanup(func() { c.Close() })
+	return c, capture
+}
+
+func TestLoggerWithFlattensFields(t *testing.T) {
+	c, capture := newCaptureClient(t)
+
+	log := c.With("component", "billing")
+	if err := log.Info("charged", "amount", 42); err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	msg := capture.last()
+	if msg["component"] != "billing" {
+		t.Fatalf("expected inherited field to be flattened, got %+v", msg)
+	}
+	if msg["amount"].(float64) != 42 {
+		t.Fatalf("expected call-site field to be flattened, got %+v", msg)
+	}
+	if msg["msg"] != "charged" {
+		t.Fatalf("expected msg field, got %+v", msg)
+	}
+}
+
+func TestLoggerWithIsCopyOnWrite(t *testing.T) {
+	c, capture := newCaptureClient(t)
+
+	base := c.With("component", "billing")
+	child := base.With("region", "us")
+
+	if err := base.Info("base event"); err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	baseMsg := capture.last()
+	if _, ok := baseMsg["region"]; ok {
+		t.Fatalf("expected parent Logger to be unaffected by child's With, got %+v", baseMsg)
+	}
+
+	if err := child.Info("child event"); err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	childMsg := capture.last()
+	if childMsg["component"] != "billing" || childMsg["region"] != "us" {
+		t.Fatalf("expected child to carry both parent and own fields, got %+v", childMsg)
+	}
+}
+
+func TestLoggerOddKeyvalsUsesErrKey(t *testing.T) {
+	c, capture := newCaptureClient(t)
+
+	if err := c.With().Info("oops", "onlykey"); err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	msg := capture.last()
+	if msg["onlykey"] != errKey {
+		t.Fatalf("expected unpaired key's value to fall back to %q, got %+v", errKey, msg)
+	}
+}
+
+func TestLoggerWithErrorAddsErrAndStack(t *testing.T) {
+	c, capture := newCaptureClient(t)
+
+	err := errorString("boom")
+	if sendErr := c.With().WithError(err).Error("failed"); sendErr != nil {
+		t.Fatalf("Error: %v", sendErr)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	msg := capture.last()
+	if msg["err"] != "boom" {
+		t.Fatalf("expected err field, got %+v", msg)
+	}
+	if _, ok := msg["stack"].(string); !ok {
+		t.Fatalf("expected stack field naming caller, got %+v", msg)
+	}
+}
+
+type errorString string
+
+func (e errorString) Error() string { return string(e) }
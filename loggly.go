@@ -2,15 +2,13 @@ package loggly
 
 import . "github.com/visionmedia/go-debug"
 import . "encoding/json"
-import "io/ioutil"
-import "net/http"
+import "context"
+import "errors"
 import "strings"
-import "bytes"
+import "sync/atomic"
 import "time"
 import "sync"
-import "fmt"
 import "os"
-import "io"
 
 const Version = "0.4.3"
 
@@ -20,6 +18,9 @@ var debug = Debug("loggly")
 
 var nl = []byte{'\n'}
 
+// ErrClosed is returned by Send and Write once the Client has been closed.
+var ErrClosed = errors.New("loggly: client closed")
+
 type Level int
 
 const (
@@ -35,9 +36,6 @@ const (
 
 // Loggly client.
 type Client struct {
-	// Optionally output logs to the given writer.
-	Writer io.Writer
-
 	// Log level defaulting to INFO.
 	Level Level
 
@@ -47,23 +45,77 @@ type Client struct {
 	// Flush interval regardless of size [5s]
 	FlushInterval time.Duration
 
-	// Loggly end-point.
-	Endpoint string
-
 	// Token string.
 	Token string
 
+	// Sinks the buffered messages are flushed to. Each sink has its own
+	// Level filter, evaluated independently of the others once a message
+	// reaches Flush, so e.g. DEBUG can go to a file sink while only
+	// ERROR+ goes to Loggly and SMTP. Note Client.Level is still the
+	// first gate: Debug/Info/... (and Logger.log) never call Send at all
+	// for a level below Client.Level, so a sink that wants DEBUG only
+	// receives it once Client.Level is lowered to DEBUG as well.
+	Sinks []SinkConfig
+
+	// Capacity of the in-process ring buffer backing Subscribe and
+	// Snapshot [1000]. It is split evenly across 16 shards, rounded down
+	// to a multiple of 16 (at least 16), so 1000 keeps 992 events.
+	RingBufferSize int
+
 	// Default properties.
 	Defaults   map[string]interface{}
-	buffer     map[string][][]byte
+	buffer     map[string][]bufEntry
 	tags       []string
 	MinimalLog bool
+	tail       *tail
+	closed     int32
+	closing    chan struct{}
+	closeOnce  sync.Once
+	closeErr   error
+	flushReq   chan struct{}
+	wg         sync.WaitGroup
 	sync.Mutex
 }
 
+// bufEntry is a single buffered, already-serialized message and the level
+// it was logged at, so sinks can apply their own filter at flush time.
+type bufEntry struct {
+	level Level
+	data  []byte
+}
+
+var levelNames = map[Level]string{
+	DEBUG:     "debug",
+	INFO:      "info",
+	NOTICE:    "notice",
+	WARNING:   "warning",
+	ERROR:     "error",
+	CRITICAL:  "critical",
+	ALERT:     "alert",
+	EMERGENCY: "emergency",
+}
+
+var levelValues = func() map[string]Level {
+	m := make(map[string]Level, len(levelNames))
+	for lvl, name := range levelNames {
+		m[name] = lvl
+	}
+	return m
+}()
+
 // New returns a new loggly client with the given `token`.
-// Optionally pass `tags` or set them later with `.Tag()`.
-func New(token string, bufferSize int, minLog bool, tags ...string) *Client {
+// Optionally pass `sinks` to fan out beyond Loggly; it otherwise defaults
+// to a single Sink that posts to Loggly's bulk HTTP endpoint at INFO.
+// Tags can be set later with `.Tag()`. Equivalent to NewWithContext with
+// context.Background().
+func New(token string, bufferSize int, minLog bool, sinks ...SinkConfig) *Client {
+	return NewWithContext(context.Background(), token, bufferSize, minLog, sinks...)
+}
+
+// NewWithContext is like New, but stops the periodic flusher once ctx is
+// done. Close should still be called to drain any buffered messages and
+// close the configured Sinks.
+func NewWithContext(ctx context.Context, token string, bufferSize int, minLog bool, sinks ...SinkConfig) *Client {
 	host, err := os.Hostname()
 	defaults := map[string]interface{}{}
 
@@ -71,26 +123,37 @@ func New(token string, bufferSize int, minLog bool, tags ...string) *Client {
 		defaults["hostname"] = host
 	}
 
-	c := &Client{
-		Level:         INFO,
-		BufferSize:    bufferSize,
-		FlushInterval: 5 * time.Second,
-		Token:         token,
-		Endpoint:      strings.Replace(api, "{token}", token, 1),
-		buffer:        make(map[string][][]byte),
-		MinimalLog:    minLog,
-		Defaults:      defaults,
+	if len(sinks) == 0 {
+		sinks = []SinkConfig{{Sink: NewLogglySink(token), Level: INFO}}
 	}
 
-	c.Tag(tags...)
+	c := &Client{
+		Level:          INFO,
+		BufferSize:     bufferSize,
+		FlushInterval:  5 * time.Second,
+		Token:          token,
+		Sinks:          sinks,
+		RingBufferSize: 1000,
+		buffer:         make(map[string][]bufEntry),
+		MinimalLog:     minLog,
+		Defaults:       defaults,
+		closing:        make(chan struct{}),
+		flushReq:       make(chan struct{}, 1),
+	}
+	c.tail = newTail(c.RingBufferSize)
 
-	go c.start()
+	c.wg.Add(1)
+	go c.run(ctx)
 
 	return c
 }
 
 // Send buffers `msg` for async sending.
 func (c *Client) Send(msg map[string]interface{}) error {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return ErrClosed
+	}
+
 	if c.MinimalLog {
 		delete(msg, "filename")
 		delete(msg, "func")
@@ -109,24 +172,46 @@ func (c *Client) Send(msg map[string]interface{}) error {
 	} else {
 		tagbuffer = "notag"
 	}
+
+	level := c.Level
+	if name, ok := msg["level"].(string); ok {
+		if lvl, ok := levelValues[name]; ok {
+			level = lvl
+		}
+	}
+
 	json, err := Marshal(msg)
 	if err != nil {
 		return err
 	}
 
 	c.Lock()
-	defer c.Unlock()
 
-	if c.Writer != nil {
-		fmt.Fprintf(c.Writer, "%s\n", string(json))
+	if atomic.LoadInt32(&c.closed) == 1 {
+		c.Unlock()
+		return ErrClosed
 	}
 
-	c.buffer[tagbuffer] = append(c.buffer[tagbuffer], json)
+	c.buffer[tagbuffer] = append(c.buffer[tagbuffer], bufEntry{level: level, data: json})
+
+	entryTags := c.tags
+	if tagbuffer != "notag" {
+		entryTags = append(append([]string{}, c.tags...), tagbuffer)
+	}
 
 	debug("buffer (%d/%d) %v", len(c.buffer[tagbuffer]), c.BufferSize, msg)
 
-	if len(c.buffer) >= c.BufferSize {
-		go c.Flush()
+	shouldFlush := len(c.buffer[tagbuffer]) >= c.BufferSize
+
+	c.Unlock()
+
+	// Recorded outside c.Mutex: subscriber fan-out (and any JSON-unmarshal
+	// based Filter matching) must not serialize every Send/Write on the
+	// client's global lock.
+	c.tail.record(Entry{Level: level, Tags: entryTags, Data: json})
+
+	if shouldFlush {
+		c.requestFlush()
 	}
 
 	return nil
@@ -134,24 +219,44 @@ func (c *Client) Send(msg map[string]interface{}) error {
 
 // Write raw data to loggly.
 func (c *Client) Write(b []byte) (int, error) {
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return 0, ErrClosed
+	}
+
 	c.Lock()
-	defer c.Unlock()
 
-	if c.Writer != nil {
-		fmt.Fprintf(c.Writer, "%s", b)
+	if atomic.LoadInt32(&c.closed) == 1 {
+		c.Unlock()
+		return 0, ErrClosed
 	}
 
-	c.buffer["notag"] = append(c.buffer["notag"], b)
+	c.buffer["notag"] = append(c.buffer["notag"], bufEntry{level: c.Level, data: b})
+	tags := c.tags
 
-	debug("buffer (%d/%d) %q", len(c.buffer), c.BufferSize, b)
+	debug("buffer (%d/%d) %q", len(c.buffer["notag"]), c.BufferSize, b)
 
-	if len(c.buffer) >= c.BufferSize {
-		go c.Flush()
+	shouldFlush := len(c.buffer["notag"]) >= c.BufferSize
+
+	c.Unlock()
+
+	c.tail.record(Entry{Level: c.Level, Tags: tags, Data: b})
+
+	if shouldFlush {
+		c.requestFlush()
 	}
 
 	return len(b), nil
 }
 
+// requestFlush wakes the background flusher. It never blocks: if a flush
+// is already pending, the request is coalesced into it.
+func (c *Client) requestFlush() {
+	select {
+	case c.flushReq <- struct{}{}:
+	default:
+	}
+}
+
 // Debug log.
 func (c *Client) Debug(t string, props ...map[string]interface{}) error {
 	if c.Level > DEBUG {
@@ -232,55 +337,71 @@ func (c *Client) Emergency(t string, props ...map[string]interface{}) error {
 	return c.Send(msg)
 }
 
-// Flush the buffered messages.
+// Flush the buffered messages to every configured sink. Each sink only
+// receives the entries that meet its own Level filter.
 func (c *Client) Flush() error {
-	for k, _ := range c.buffer {
-		if len(c.buffer[k]) == 0 {
-			debug("no messages to flush")
-			continue
-		}
-		//Lock mutex per buffer in map
+	var errs MultiError
+
+	c.Lock()
+	keys := make([]string, 0, len(c.buffer))
+	for k := range c.buffer {
+		keys = append(keys, k)
+	}
+	baseTags := append([]string{}, c.tags...)
+	c.Unlock()
+
+	for _, k := range keys {
 		c.Lock()
-		debug("flushing %d messages", len(c.buffer[k]))
-		body := bytes.Join(c.buffer[k], nl)
-		//release mutex after buffer emptyed
-		c.Unlock()
+		entries := c.buffer[k]
 		c.buffer[k] = nil
+		c.Unlock()
 
-		client := &http.Client{}
-		debug("POST %s with %d bytes", c.Endpoint, len(body))
-		req, err := http.NewRequest("POST", c.Endpoint, bytes.NewBuffer(body))
-		if err != nil {
-			debug("error: %v", err)
-			return err
+		if len(entries) == 0 {
+			debug("no messages to flush")
+			continue
 		}
 
-		req.Header.Add("User-Agent", "go-loggly (version: "+Version+")")
-		req.Header.Add("Content-Type", "text/plain")
-		req.Header.Add("Content-Length", string(len(body)))
-
-		tags := k
-		if tags != "notag" {
-			req.Header.Add("X-Loggly-Tag", tags)
+		tags := baseTags
+		if k != "notag" {
+			tags = append(append([]string{}, baseTags...), k)
 		}
 
-		res, err := client.Do(req)
-		if err != nil {
-			debug("error: %v", err)
-			return err
+		for _, sc := range c.Sinks {
+			batch := make([][]byte, 0, len(entries))
+			for _, e := range entries {
+				if e.level >= sc.Level {
+					batch = append(batch, e.data)
+				}
+			}
+			if len(batch) == 0 {
+				continue
+			}
+
+			debug("flushing %d messages to %T", len(batch), sc.Sink)
+			if err := sc.Sink.Write(batch, tags); err != nil {
+				debug("error: %v", err)
+				errs = append(errs, err)
+			}
 		}
+	}
 
-		defer res.Body.Close()
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
 
-		debug("%d response", res.StatusCode)
-		if res.StatusCode >= 400 {
-			resp, _ := ioutil.ReadAll(res.Body)
-			debug("error: %s", string(resp))
-			return err
-		}
+// Subscribe streams future events matching filter to the returned
+// channel in real time. A slow consumer is dropped (its channel is closed)
+// rather than blocking Send; call cancel once the subscriber is done to
+// release its channel.
+func (c *Client) Subscribe(filter Filter) (events <-chan Entry, cancel func()) {
+	return c.tail.subscribe(filter)
+}
 
-	}
-	return nil
+// Snapshot returns up to the last n events recorded by Send and Write.
+func (c *Client) Snapshot(n int) []Entry {
+	return c.tail.snapshot(n)
 }
 
 // Tag adds the given `tags` for all logs.
@@ -301,12 +422,61 @@ func (c *Client) tagsList() string {
 	return strings.Join(c.tags, ",")
 }
 
-// Start flusher.
-func (c *Client) start() {
+// Close stops the periodic flusher, drains the buffer with a final
+// synchronous Flush, and closes every configured Sink (draining durable
+// spools such as SpoolSink). Send and Write return ErrClosed afterwards.
+// Safe to call more than once; later calls return the same result as the
+// first.
+func (c *Client) Close() error {
+	c.Lock()
+	atomic.StoreInt32(&c.closed, 1)
+	c.Unlock()
+
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		c.wg.Wait()
+
+		var errs MultiError
+
+		if err := c.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+
+		for _, sc := range c.Sinks {
+			if err := sc.Sink.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if len(errs) != 0 {
+			c.closeErr = errs
+		}
+	})
+
+	return c.closeErr
+}
+
+// run is the single background flusher goroutine, coordinated by
+// flushReq instead of spawning an unbounded `go c.Flush()` per Send/Write.
+// It stops once ctx is done or Close is called.
+func (c *Client) run(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.FlushInterval)
+	defer ticker.Stop()
+
 	for {
-		time.Sleep(c.FlushInterval)
-		debug("interval %v reached", c.FlushInterval)
-		c.Flush()
+		select {
+		case <-ticker.C:
+			debug("interval %v reached", c.FlushInterval)
+			c.Flush()
+		case <-c.flushReq:
+			c.Flush()
+		case <-ctx.Done():
+			return
+		case <-c.closing:
+			return
+		}
 	}
 }
 
@@ -0,0 +1,44 @@
+package loggly
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+type nopSink struct{}
+
+func (nopSink) Write(batch [][]byte, tags []string) error { return nil }
+func (nopSink) Close() error                              { return nil }
+
+// TestCloseNoGoroutineLeak verifies the background flusher started by New
+// actually exits once Close returns, and that Close is safe to call twice.
+func TestCloseNoGoroutineLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	c := New("token", 10, false, SinkConfig{Sink: nopSink{}, Level: DEBUG})
+	c.Info("hello")
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	if _, err := c.Write([]byte("x")); err != ErrClosed {
+		t.Fatalf("Write after Close = %v, want ErrClosed", err)
+	}
+	if err := c.Send(map[string]interface{}{"msg": "x"}); err != ErrClosed {
+		t.Fatalf("Send after Close = %v, want ErrClosed", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("goroutine leak after Close: before=%d after=%d", before, after)
+	}
+}
@@ -0,0 +1,329 @@
+package loggly
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Sink receives batches of already-serialized log events. A batch is the
+// set of JSON-encoded messages accumulated for a single tag since the last
+// flush; `tags` holds the tag(s) the batch was buffered under.
+type Sink interface {
+	Write(batch [][]byte, tags []string) error
+	Close() error
+}
+
+// SinkConfig pairs a Sink with the minimum Level it should receive. A
+// Client evaluates this filter per sink, so different sinks can see
+// different slices of the same stream (e.g. DEBUG to a file, ERROR+ to
+// Loggly and SMTP).
+type SinkConfig struct {
+	Sink  Sink
+	Level Level
+}
+
+// MultiError aggregates the errors returned by a MultiSink's member sinks.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	parts := make([]string, len(m))
+	for i, err := range m {
+		parts[i] = err.Error()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// MultiSink fans a batch out to every member Sink in parallel, returning a
+// MultiError if any of them fail.
+type MultiSink struct {
+	Sinks []Sink
+}
+
+// NewMultiSink returns a MultiSink that writes to all of the given sinks.
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{Sinks: sinks}
+}
+
+func (m *MultiSink) Write(batch [][]byte, tags []string) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs MultiError
+	)
+
+	for _, sink := range m.Sinks {
+		wg.Add(1)
+		go func(s Sink) {
+			defer wg.Done()
+			if err := s.Write(batch, tags); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(sink)
+	}
+
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (m *MultiSink) Close() error {
+	var errs MultiError
+	for _, sink := range m.Sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// LogglySink POSTs batches to Loggly's bulk HTTP endpoint, tagging the
+// request with `X-Loggly-Tag` when a tag is present. This is the default
+// sink used by New.
+type LogglySink struct {
+	Endpoint string
+	client   *http.Client
+}
+
+// NewLogglySink returns a Sink that writes to Loggly's bulk endpoint for
+// the given token.
+func NewLogglySink(token string) *LogglySink {
+	return &LogglySink{
+		Endpoint: strings.Replace(api, "{token}", token, 1),
+		client:   &http.Client{},
+	}
+}
+
+func (s *LogglySink) Write(batch [][]byte, tags []string) error {
+	body := bytes.Join(batch, nl)
+
+	debug("POST %s with %d bytes", s.Endpoint, len(body))
+	req, err := http.NewRequest("POST", s.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("User-Agent", "go-loggly (version: "+Version+")")
+	req.Header.Add("Content-Type", "text/plain")
+	req.Header.Add("Content-Length", strconv.Itoa(len(body)))
+
+	if tag := strings.Join(tags, ","); tag != "" {
+		req.Header.Add("X-Loggly-Tag", tag)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	debug("%d response", res.StatusCode)
+	if res.StatusCode >= 400 {
+		resp, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("loggly: %d response: %s", res.StatusCode, resp)
+	}
+
+	return nil
+}
+
+func (s *LogglySink) Close() error {
+	return nil
+}
+
+// ConsoleSink writes batches to an io.Writer, one message per line. It
+// defaults to os.Stdout and replaces the old `Client.Writer` side-output.
+type ConsoleSink struct {
+	Writer io.Writer
+	mu     sync.Mutex
+}
+
+// NewConsoleSink returns a Sink that writes to w, or os.Stdout if w is nil.
+func NewConsoleSink(w io.Writer) *ConsoleSink {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &ConsoleSink{Writer: w}
+}
+
+func (s *ConsoleSink) Write(batch [][]byte, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, msg := range batch {
+		if _, err := fmt.Fprintf(s.Writer, "%s\n", msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close is a no-op: Writer is caller-supplied (or the process's
+// os.Stdout) and ConsoleSink doesn't own its lifecycle, so closing it
+// here would e.g. take stdout out from under the rest of the process.
+func (s *ConsoleSink) Close() error {
+	return nil
+}
+
+// FileSink appends batches to a file, rotating it to `path.1` before a
+// write that would take it past MaxBytes.
+type FileSink struct {
+	Path     string
+	MaxBytes int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileSink returns a Sink that appends to path, rotating before a write
+// that would take the file past maxBytes (0 disables rotation).
+func NewFileSink(path string, maxBytes int64) (*FileSink, error) {
+	s := &FileSink{Path: path, MaxBytes: maxBytes}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(s.Path, s.Path+".1"); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileSink) Write(batch [][]byte, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body := append(bytes.Join(batch, nl), '\n')
+
+	if s.MaxBytes > 0 && s.size+int64(len(body)) > s.MaxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(body)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// SMTPSink emails each batch, intended for ERROR-and-up alerting.
+type SMTPSink struct {
+	Addr    string
+	Auth    smtp.Auth
+	From    string
+	To      []string
+	Subject string
+}
+
+// NewSMTPSink returns a Sink that emails batches from `from` to `to` via
+// the SMTP server at addr.
+func NewSMTPSink(addr string, auth smtp.Auth, from string, to []string, subject string) *SMTPSink {
+	return &SMTPSink{Addr: addr, Auth: auth, From: from, To: to, Subject: subject}
+}
+
+func (s *SMTPSink) Write(batch [][]byte, tags []string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Subject: %s\r\n", s.Subject)
+	fmt.Fprintf(&buf, "From: %s\r\n", s.From)
+	fmt.Fprintf(&buf, "To: %s\r\n\r\n", strings.Join(s.To, ", "))
+	buf.Write(bytes.Join(batch, nl))
+
+	return smtp.SendMail(s.Addr, s.Auth, s.From, s.To, buf.Bytes())
+}
+
+func (s *SMTPSink) Close() error {
+	return nil
+}
+
+// ElasticsearchSink writes batches to an Elasticsearch `_bulk` endpoint,
+// indexing each message into Index.
+type ElasticsearchSink struct {
+	URL   string
+	Index string
+
+	client *http.Client
+}
+
+// NewElasticsearchSink returns a Sink that bulk-indexes into index on the
+// Elasticsearch cluster at url.
+func NewElasticsearchSink(url string, index string) *ElasticsearchSink {
+	return &ElasticsearchSink{
+		URL:    strings.TrimRight(url, "/"),
+		Index:  index,
+		client: &http.Client{},
+	}
+}
+
+func (s *ElasticsearchSink) Write(batch [][]byte, tags []string) error {
+	var buf bytes.Buffer
+	for _, msg := range batch {
+		fmt.Fprintf(&buf, `{"index":{"_index":%q}}`+"\n", s.Index)
+		buf.Write(msg)
+		buf.Write(nl)
+	}
+
+	req, err := http.NewRequest("POST", s.URL+"/_bulk", &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/x-ndjson")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		resp, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("elasticsearch: %d response: %s", res.StatusCode, resp)
+	}
+
+	return nil
+}
+
+func (s *ElasticsearchSink) Close() error {
+	return nil
+}
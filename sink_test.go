@@ -0,0 +1,92 @@
+package loggly
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMultiSinkFansOutAndAggregatesErrors(t *testing.T) {
+	var a, b countingSink
+	failing := failSink{err: errors.New("boom")}
+
+	m := NewMultiSink(&a, &b, &failing)
+
+	batch := [][]byte{[]byte(`{"msg":"hi"}`)}
+	err := m.Write(batch, []string{"tag"})
+
+	if a.writes != 1 || b.writes != 1 {
+		t.Fatalf("expected both sinks written to, got a=%d b=%d", a.writes, b.writes)
+	}
+
+	var merr MultiError
+	if !errors.As(err, &merr) || len(merr) != 1 {
+		t.Fatalf("expected a MultiError with 1 error, got %v", err)
+	}
+}
+
+func TestMultiSinkClose(t *testing.T) {
+	var a, b countingSink
+	m := NewMultiSink(&a, &b)
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if a.closed != 1 || b.closed != 1 {
+		t.Fatalf("expected both sinks closed once, got a=%d b=%d", a.closed, b.closed)
+	}
+}
+
+func TestFileSinkRotates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.log")
+
+	sink, err := NewFileSink(path, 10)
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.Write([][]byte{[]byte("0123456789")}, nil); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := sink.Write([][]byte{[]byte("more")}, nil); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("expected rotated file %s.1 to exist: %v", path, err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Contains(got, []byte("more")) {
+		t.Fatalf("expected current file to contain post-rotation write, got %q", got)
+	}
+}
+
+type countingSink struct {
+	writes int
+	closed int
+}
+
+func (s *countingSink) Write(batch [][]byte, tags []string) error {
+	s.writes++
+	return nil
+}
+
+func (s *countingSink) Close() error {
+	s.closed++
+	return nil
+}
+
+type failSink struct {
+	err error
+}
+
+func (s *failSink) Write(batch [][]byte, tags []string) error { return s.err }
+func (s *failSink) Close() error                              { return nil }
@@ -0,0 +1,246 @@
+package loggly
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpoolSink wraps another Sink with a durable, on-disk spool and
+// exponential-backoff retry, so a network blip or a Loggly 5xx no longer
+// loses the batch. Batches are persisted to Dir before being handed to the
+// underlying Sink, deleted once it accepts them or once they are dropped
+// after MaxAttempts, and otherwise retried on a goroutine independent of
+// the ingest path. Each tag gets its
+// own sub-spool and retry queue, so one tag stuck retrying a 4xx can't
+// block delivery for the others.
+type SpoolSink struct {
+	// Sink batches are ultimately delivered to.
+	Sink Sink
+
+	// Directory batches are spooled to before delivery.
+	Dir string
+
+	// Total bytes allowed across all sub-spools before new batches are
+	// dropped [no limit].
+	MaxSpoolBytes int64
+
+	// Backoff between retries, doubling each attempt up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Fraction of the backoff duration randomized to avoid thundering
+	// herds, e.g. 0.2 for +/-20%.
+	Jitter float64
+
+	// Delivery attempts, the first try included, before a batch is
+	// dropped [10].
+	MaxAttempts int
+
+	// Per-tag in-memory queue depth before a new batch is dropped [100].
+	QueueSize int
+
+	// How long Close waits for in-flight retries to finish before giving
+	// up and leaving whatever remains on disk.
+	DrainTimeout time.Duration
+
+	pending int64
+	retried int64
+	dropped int64
+	size    int64
+	seq     uint64
+
+	mu       sync.Mutex
+	queues   map[string]chan spoolJob
+	wg       sync.WaitGroup
+	closing  chan struct{}
+	once     sync.Once
+	closeErr error
+}
+
+type spoolJob struct {
+	path string
+	tags []string
+	size int64 // bytes written to path, tracked here since a later read may fail
+}
+
+// NewSpoolSink returns a SpoolSink that spools to dir before delivering to
+// sink, applying sensible retry defaults.
+func NewSpoolSink(sink Sink, dir string) *SpoolSink {
+	return &SpoolSink{
+		Sink:           sink,
+		Dir:            dir,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Jitter:         0.2,
+		MaxAttempts:    10,
+		QueueSize:      100,
+		DrainTimeout:   30 * time.Second,
+		queues:         make(map[string]chan spoolJob),
+		closing:        make(chan struct{}),
+	}
+}
+
+// Pending returns the number of batches currently spooled or in flight.
+func (s *SpoolSink) Pending() int64 { return atomic.LoadInt64(&s.pending) }
+
+// Retried returns the number of retry attempts made so far.
+func (s *SpoolSink) Retried() int64 { return atomic.LoadInt64(&s.retried) }
+
+// Dropped returns the number of batches abandoned after MaxAttempts, or
+// rejected outright because the spool or a tag's queue was full.
+func (s *SpoolSink) Dropped() int64 { return atomic.LoadInt64(&s.dropped) }
+
+func (s *SpoolSink) Write(batch [][]byte, tags []string) error {
+	select {
+	case <-s.closing:
+		return fmt.Errorf("spool: %s is closed", s.Dir)
+	default:
+	}
+
+	body := bytes.Join(batch, nl)
+
+	if s.MaxSpoolBytes > 0 && atomic.LoadInt64(&s.size)+int64(len(body)) > s.MaxSpoolBytes {
+		atomic.AddInt64(&s.dropped, 1)
+		return fmt.Errorf("spool: %s over MaxSpoolBytes, dropping batch", s.Dir)
+	}
+
+	tagKey := spoolTagKey(tags)
+	dir := filepath.Join(s.Dir, tagKey)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, strconv.FormatUint(atomic.AddUint64(&s.seq, 1), 10)+".batch")
+	if err := os.WriteFile(path, body, 0644); err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.size, int64(len(body)))
+
+	select {
+	case s.queueFor(tagKey) <- spoolJob{path: path, tags: tags, size: int64(len(body))}:
+		atomic.AddInt64(&s.pending, 1)
+		return nil
+	default:
+		os.Remove(path)
+		atomic.AddInt64(&s.size, -int64(len(body)))
+		atomic.AddInt64(&s.dropped, 1)
+		return fmt.Errorf("spool: queue for tag %q full, dropping batch", tagKey)
+	}
+}
+
+func (s *SpoolSink) queueFor(tagKey string) chan spoolJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue, ok := s.queues[tagKey]
+	if !ok {
+		queue = make(chan spoolJob, s.QueueSize)
+		s.queues[tagKey] = queue
+		s.wg.Add(1)
+		go s.worker(queue)
+	}
+	return queue
+}
+
+func (s *SpoolSink) worker(queue chan spoolJob) {
+	defer s.wg.Done()
+
+	for job := range queue {
+		s.deliver(job)
+	}
+}
+
+func (s *SpoolSink) deliver(job spoolJob) {
+	backoff := s.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		body, err := os.ReadFile(job.path)
+		if err == nil {
+			err = s.Sink.Write(bytes.Split(body, nl), job.tags)
+		}
+
+		if err == nil {
+			os.Remove(job.path)
+			atomic.AddInt64(&s.size, -job.size)
+			atomic.AddInt64(&s.pending, -1)
+			return
+		}
+
+		debug("spool: delivery of %s failed (attempt %d): %v", job.path, attempt, err)
+
+		if attempt >= s.MaxAttempts {
+			os.Remove(job.path)
+			atomic.AddInt64(&s.size, -job.size)
+			atomic.AddInt64(&s.pending, -1)
+			atomic.AddInt64(&s.dropped, 1)
+			return
+		}
+
+		atomic.AddInt64(&s.retried, 1)
+
+		select {
+		case <-time.After(jitter(backoff, s.Jitter)):
+		case <-s.closing:
+		}
+
+		if backoff *= 2; backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+func jitter(d time.Duration, frac float64) time.Duration {
+	if frac <= 0 {
+		return d
+	}
+	delta := float64(d) * frac
+	return d + time.Duration(delta*(2*rand.Float64()-1))
+}
+
+func spoolTagKey(tags []string) string {
+	if len(tags) == 0 {
+		return "notag"
+	}
+	r := strings.NewReplacer("/", "_", string(os.PathSeparator), "_")
+	return r.Replace(strings.Join(tags, ","))
+}
+
+// Close stops accepting new batches, waits up to DrainTimeout for
+// in-flight retries to finish, and then closes the underlying Sink.
+// Batches that haven't drained in time are left on disk; a SpoolSink does
+// not read back batch files already present in Dir. Safe to call more than
+// once; later calls return the same result as the first.
+func (s *SpoolSink) Close() error {
+	s.once.Do(func() {
+		close(s.closing)
+
+		s.mu.Lock()
+		for _, queue := range s.queues {
+			close(queue)
+		}
+		s.mu.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			s.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			s.closeErr = s.Sink.Close()
+		case <-time.After(s.DrainTimeout):
+			s.closeErr = fmt.Errorf("spool: %s still draining after %s", s.Dir, s.DrainTimeout)
+		}
+	})
+
+	return s.closeErr
+}
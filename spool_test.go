@@ -0,0 +1,140 @@
+package loggly
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakySink fails the first N writes for a given tag, then succeeds.
+type flakySink struct {
+	mu       sync.Mutex
+	failLeft map[string]int
+	writes   []string // tag of each successful write, in order
+}
+
+func (s *flakySink) Write(batch [][]byte, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag := tagKeyFor(tags)
+	if s.failLeft[tag] > 0 {
+		s.failLeft[tag]--
+		return errors.New("temporary failure")
+	}
+	s.writes = append(s.writes, tag)
+	return nil
+}
+
+func (s *flakySink) Close() error { return nil }
+
+func tagKeyFor(tags []string) string {
+	if len(tags) == 0 {
+		return "notag"
+	}
+	return tags[0]
+}
+
+func TestSpoolSinkRetriesUntilSuccess(t *testing.T) {
+	dir := t.TempDir()
+	inner := &flakySink{failLeft: map[string]int{"a": 2}}
+
+	s := NewSpoolSink(inner, dir)
+	s.InitialBackoff = time.Millisecond
+	s.MaxBackoff = 5 * time.Millisecond
+	s.MaxAttempts = 5
+
+	if err := s.Write([][]byte{[]byte(`{"msg":"1"}`)}, []string{"a"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if s.Pending() != 0 {
+		t.Fatalf("expected batch to drain, Pending=%d", s.Pending())
+	}
+	if s.Retried() < 2 {
+		t.Fatalf("expected at least 2 retries, got %d", s.Retried())
+	}
+	if s.Dropped() != 0 {
+		t.Fatalf("expected nothing dropped, got %d", s.Dropped())
+	}
+
+	inner.mu.Lock()
+	defer inner.mu.Unlock()
+	if len(inner.writes) != 1 || inner.writes[0] != "a" {
+		t.Fatalf("expected exactly one successful delivery for tag a, got %v", inner.writes)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestSpoolSinkDropsAfterMaxAttempts(t *testing.T) {
+	dir := t.TempDir()
+	inner := &flakySink{failLeft: map[string]int{"a": 1000}}
+
+	s := NewSpoolSink(inner, dir)
+	s.InitialBackoff = time.Millisecond
+	s.MaxBackoff = 2 * time.Millisecond
+	s.MaxAttempts = 3
+	s.DrainTimeout = time.Second
+	defer s.Close()
+
+	if err := s.Write([][]byte{[]byte(`{"msg":"1"}`)}, []string{"a"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Dropped() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if s.Dropped() != 1 {
+		t.Fatalf("expected batch to be dropped after MaxAttempts, Dropped=%d", s.Dropped())
+	}
+	if s.Pending() != 0 {
+		t.Fatalf("expected Pending back to 0, got %d", s.Pending())
+	}
+}
+
+func TestSpoolSinkPerTagIsolation(t *testing.T) {
+	dir := t.TempDir()
+	inner := &flakySink{failLeft: map[string]int{"bad": 1000}}
+
+	s := NewSpoolSink(inner, dir)
+	s.InitialBackoff = time.Millisecond
+	s.MaxBackoff = 2 * time.Millisecond
+	s.MaxAttempts = 1000
+	s.DrainTimeout = 50 * time.Millisecond
+	defer s.Close()
+
+	if err := s.Write([][]byte{[]byte(`{"msg":"bad"}`)}, []string{"bad"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := s.Write([][]byte{[]byte(`{"msg":"good"}`)}, []string{"good"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		inner.mu.Lock()
+		delivered := len(inner.writes) > 0
+		inner.mu.Unlock()
+		if delivered || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	inner.mu.Lock()
+	defer inner.mu.Unlock()
+	if len(inner.writes) != 1 || inner.writes[0] != "good" {
+		t.Fatalf("expected tag %q to deliver independently of stuck tag %q, got %v", "good", "bad", inner.writes)
+	}
+}
@@ -0,0 +1,224 @@
+package loggly
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Entry is a single event recorded by the ring buffer, as delivered to
+// Subscribe channels and returned by Snapshot.
+type Entry struct {
+	Level Level
+	Tags  []string
+	Data  []byte
+}
+
+// Filter selects which Entries a Subscribe call receives.
+type Filter struct {
+	// Minimum level an Entry must have.
+	MinLevel Level
+
+	// If non-empty, an Entry's Tags must contain Tag.
+	Tag string
+
+	// If Field is non-empty, an Entry's decoded JSON must have a value
+	// equal to Value under that key.
+	Field string
+	Value interface{}
+}
+
+func (f Filter) match(e Entry) bool {
+	if e.Level < f.MinLevel {
+		return false
+	}
+
+	if f.Tag != "" {
+		found := false
+		for _, t := range e.Tags {
+			if t == f.Tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if f.Field != "" {
+		var msg map[string]interface{}
+		if err := json.Unmarshal(e.Data, &msg); err != nil {
+			return false
+		}
+		if v, ok := msg[f.Field]; !ok || !fieldEqual(v, f.Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fieldEqual compares a value decoded from JSON (where all numbers are
+// float64) against a caller-supplied Filter.Value, which is commonly a Go
+// int literal. Both sides are coerced to float64 when numeric so e.g.
+// Filter{Field: "code", Value: 42} matches a decoded "code":42.
+func fieldEqual(a, b interface{}) bool {
+	if af, ok := toFloat64(a); ok {
+		if bf, ok := toFloat64(b); ok {
+			return af == bf
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+const (
+	tailShardCount   = 16
+	subscriberBuffer = 32
+)
+
+// tail is an in-process, fixed-capacity ring buffer of recent Entries
+// plus a set of live Subscribe channels. The ring is sharded so writes to
+// it don't all serialize on one mutex; fan-out to subscribers still takes
+// the single t.mu on every record.
+type tail struct {
+	shards [tailShardCount]*tailShard
+	seq    uint64
+
+	mu     sync.Mutex
+	subs   map[int]*subscription
+	nextID int
+}
+
+type tailShard struct {
+	mu   sync.Mutex
+	buf  []Entry
+	next int
+	full bool
+}
+
+type subscription struct {
+	ch     chan Entry
+	filter Filter
+}
+
+func newTail(capacity int) *tail {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+
+	perShard := capacity / tailShardCount
+	if perShard < 1 {
+		perShard = 1
+	}
+
+	t := &tail{subs: make(map[int]*subscription)}
+	for i := range t.shards {
+		t.shards[i] = &tailShard{buf: make([]Entry, perShard)}
+	}
+	return t
+}
+
+// record adds e to the ring buffer and fans it out to matching subscribers.
+func (t *tail) record(e Entry) {
+	shard := t.shards[atomic.AddUint64(&t.seq, 1)%tailShardCount]
+
+	shard.mu.Lock()
+	shard.buf[shard.next] = e
+	shard.next++
+	if shard.next == len(shard.buf) {
+		shard.next = 0
+		shard.full = true
+	}
+	shard.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, sub := range t.subs {
+		if !sub.filter.match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			debug("tail: slow subscriber, dropping it")
+			delete(t.subs, id)
+			close(sub.ch)
+		}
+	}
+}
+
+func (t *tail) subscribe(filter Filter) (<-chan Entry, func()) {
+	ch := make(chan Entry, subscriberBuffer)
+
+	t.mu.Lock()
+	id := t.nextID
+	t.nextID++
+	t.subs[id] = &subscription{ch: ch, filter: filter}
+	t.mu.Unlock()
+
+	cancel := func() {
+		t.mu.Lock()
+		delete(t.subs, id)
+		t.mu.Unlock()
+	}
+
+	return ch, cancel
+}
+
+// snapshot returns up to the last n recorded entries. Order is recency
+// per shard, but not strictly global across shards.
+func (t *tail) snapshot(n int) []Entry {
+	var all []Entry
+
+	for _, shard := range t.shards {
+		shard.mu.Lock()
+		if shard.full {
+			all = append(all, shard.buf[shard.next:]...)
+			all = append(all, shard.buf[:shard.next]...)
+		} else {
+			all = append(all, shard.buf[:shard.next]...)
+		}
+		shard.mu.Unlock()
+	}
+
+	if n <= 0 || n >= len(all) {
+		return all
+	}
+	return all[len(all)-n:]
+}
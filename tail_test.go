@@ -0,0 +1,86 @@
+package loggly
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTailSubscribeFiltersByLevelAndTag(t *testing.T) {
+	tl := newTail(64)
+
+	events, cancel := tl.subscribe(Filter{MinLevel: ERROR, Tag: "billing"})
+	defer cancel()
+
+	tl.record(Entry{Level: INFO, Tags: []string{"billing"}, Data: []byte(`{}`)})
+	tl.record(Entry{Level: ERROR, Tags: []string{"other"}, Data: []byte(`{}`)})
+	tl.record(Entry{Level: ERROR, Tags: []string{"billing"}, Data: []byte(`{"code":42}`)})
+
+	select {
+	case e := <-events:
+		if e.Level != ERROR || tagKeyFor(e.Tags) != "billing" {
+			t.Fatalf("unexpected entry delivered: %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for matching entry")
+	}
+
+	select {
+	case e := <-events:
+		t.Fatalf("did not expect a second entry, got %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTailSubscribeFieldFilterCoercesNumericTypes(t *testing.T) {
+	tl := newTail(64)
+
+	events, cancel := tl.subscribe(Filter{Field: "code", Value: 42})
+	defer cancel()
+
+	tl.record(Entry{Data: []byte(`{"code":7}`)})
+	tl.record(Entry{Data: []byte(`{"code":42}`)})
+
+	select {
+	case e := <-events:
+		if string(e.Data) != `{"code":42}` {
+			t.Fatalf("unexpected entry delivered: %s", e.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for matching entry")
+	}
+}
+
+func TestTailCancelStopsDelivery(t *testing.T) {
+	tl := newTail(64)
+
+	events, cancel := tl.subscribe(Filter{})
+	cancel()
+
+	tl.record(Entry{Data: []byte(`{}`)})
+
+	select {
+	case e, ok := <-events:
+		if ok {
+			t.Fatalf("expected channel closed or empty after cancel, got %+v", e)
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTailSnapshotReturnsLastN(t *testing.T) {
+	tl := newTail(8) // small capacity, spread across shards
+
+	for i := 0; i < 100; i++ {
+		tl.record(Entry{Level: INFO, Data: []byte(`{}`)})
+	}
+
+	snap := tl.snapshot(5)
+	if len(snap) != 5 {
+		t.Fatalf("expected Snapshot(5) to return 5 entries once the ring has more, got %d", len(snap))
+	}
+
+	full := tl.snapshot(0)
+	if len(full) > tailShardCount {
+		t.Fatalf("ring holds more than its per-shard capacity: %d entries across %d shards", len(full), tailShardCount)
+	}
+}